@@ -6,6 +6,51 @@ use aya_bpf::{
     programs::XdpContext,
 };
 
+/// Max entries of the CONNECTION map, each connection takes two of them.
+pub const CONNECTION_MAX_ENTRIES: u32 = 1024;
+
+/// Max entries of the queue of free local ports.
+pub const SERVICE_PORTS_MAX_ENTRIES: u32 = 50000;
+
+/// Why the XDP program dropped a packet of a new connection, used as the index of the
+/// DROP_COUNTER map.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum DropReason {
+    NoLocalIp,
+    NoLocalPort,
+    ConnectionMapFull,
+}
+
+impl DropReason {
+    pub const ALL: [DropReason; 3] = [
+        DropReason::NoLocalIp,
+        DropReason::NoLocalPort,
+        DropReason::ConnectionMapFull,
+    ];
+
+    pub const COUNT: u32 = Self::ALL.len() as u32;
+
+    pub fn index(&self) -> u32 {
+        *self as u32
+    }
+}
+
 #[map]
-static SERVICE_PP: Queue<u16> = Queue::with_max_entries(50000, 0);
+static SERVICE_PP: Queue<u16> = Queue::with_max_entries(SERVICE_PORTS_MAX_ENTRIES, 0);
 static list: [&Queue<u16>; 1] = [&SERVICE_PP];
+
+mod test {
+
+    #[test]
+    fn test_drop_reason_index() {
+        use super::DropReason;
+
+        DropReason::ALL
+            .iter()
+            .enumerate()
+            .for_each(|(i, reason)| assert_eq!(i as u32, reason.index()));
+        assert!(DropReason::ALL
+            .iter()
+            .all(|reason| reason.index() < DropReason::COUNT));
+    }
+}
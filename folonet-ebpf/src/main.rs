@@ -6,7 +6,7 @@ use aya_bpf::{
     bindings::xdp_action,
     helpers::bpf_csum_diff,
     macros::{map, xdp},
-    maps::{HashMap, Queue, RingBuf},
+    maps::{HashMap, PerCpuArray, Queue, RingBuf},
     programs::XdpContext,
 };
 
@@ -16,7 +16,10 @@ use core::{
     ptr::copy,
 };
 use folonet_common::{
-    csum_fold_helper, event::Event, BiPort, KConnection, KEndpoint, L4Hdr, Mac, Notification,
+    csum_fold_helper,
+    event::Event,
+    maps::{DropReason, CONNECTION_MAX_ENTRIES, SERVICE_PORTS_MAX_ENTRIES},
+    BiPort, KConnection, KEndpoint, L4Hdr, Mac, Notification,
 };
 use network_types::{
     eth::{EthHdr, EtherType},
@@ -52,7 +55,8 @@ fn ptr_at<T>(ctx: &XdpContext, offset: usize) -> Result<*mut T, ()> {
 }
 
 #[map]
-static CONNECTION: HashMap<KConnection, KConnection> = HashMap::with_max_entries(1024, 0);
+static CONNECTION: HashMap<KConnection, KConnection> =
+    HashMap::with_max_entries(CONNECTION_MAX_ENTRIES, 0);
 
 #[map]
 static SERVER_MAP: HashMap<KEndpoint, KEndpoint> = HashMap::with_max_entries(1024, 0);
@@ -64,11 +68,24 @@ static IP_MAC_MAP: HashMap<u32, Mac> = HashMap::with_max_entries(1024, 0);
 static PACKET_EVENT: RingBuf = RingBuf::with_byte_size(256 * 1024, 0);
 
 #[map]
-static SERVICE_PORTS_1: Queue<u16> = Queue::with_max_entries(50000, 0);
+static SERVICE_PORTS_1: Queue<u16> = Queue::with_max_entries(SERVICE_PORTS_MAX_ENTRIES, 0);
 
 #[map]
 static LOCAL_IP_MAP: HashMap<u32, u32> = HashMap::with_max_entries(10, 0);
 
+#[map]
+static DROP_COUNTER: PerCpuArray<u64> = PerCpuArray::with_max_entries(DropReason::COUNT, 0);
+
+// count the drop instead of logging it, as it happens for every packet of the
+// connection while the resource is exhausted
+#[inline(always)]
+fn drop_packet(reason: DropReason) -> u32 {
+    if let Some(counter) = DROP_COUNTER.get_ptr_mut(reason.index()) {
+        unsafe { *counter += 1 };
+    }
+    xdp_action::XDP_DROP
+}
+
 #[inline(always)]
 fn extract_way(
     ethhdr: *const EthHdr,
@@ -249,29 +266,34 @@ fn try_xdp_firewall(ctx: XdpContext) -> Result<u32, ()> {
             Some(to) => to,
             None => return Ok(xdp_action::XDP_PASS),
         };
-        let from_port = SERVICE_PORTS_1.pop();
-        if from_port.is_none() {
-            return Ok(xdp_action::XDP_DROP);
-        }
-        let from_port = from_port.unwrap();
-        let local_ip = unsafe { LOCAL_IP_MAP.get(&ifidx) };
-        if local_ip.is_none() {
-            return Ok(xdp_action::XDP_DROP);
-        }
-        let local_ip = local_ip.unwrap();
+        let local_ip = match unsafe { LOCAL_IP_MAP.get(&ifidx) } {
+            Some(local_ip) => local_ip,
+            None => return Ok(drop_packet(DropReason::NoLocalIp)),
+        };
+        // once popped, the port must be pushed back if the connection isn't recorded
+        let from_port = match SERVICE_PORTS_1.pop() {
+            Some(from_port) => from_port,
+            None => return Ok(drop_packet(DropReason::NoLocalPort)),
+        };
         let from = KEndpoint::new(local_ip.to_be(), from_port.to_be());
 
         let out_way = KConnection { from, to: *to };
-        CONNECTION
-            .insert(&declare_way, &out_way, 0)
-            .map_err(|_| ())?;
+        if CONNECTION.insert(&declare_way, &out_way, 0).is_err() {
+            let _ = SERVICE_PORTS_1.push(&from_port, 0);
+            return Ok(drop_packet(DropReason::ConnectionMapFull));
+        }
 
         // and, we need to record the return way
         let return_output_way = out_way.reverse();
         let return_declare_way = &declare_way.reverse();
-        CONNECTION
+        if CONNECTION
             .insert(&return_output_way, &return_declare_way, 0)
-            .map_err(|_| ())?;
+            .is_err()
+        {
+            let _ = CONNECTION.remove(&declare_way);
+            let _ = SERVICE_PORTS_1.push(&from_port, 0);
+            return Ok(drop_packet(DropReason::ConnectionMapFull));
+        }
     }
 
     let output_way = unsafe { CONNECTION.get(&declare_way) };
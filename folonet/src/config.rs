@@ -1,3 +1,10 @@
+use std::{
+    net::{Ipv4Addr, SocketAddr},
+    ops::Range,
+};
+
+use anyhow::{bail, Context};
+use folonet_common::maps::SERVICE_PORTS_MAX_ENTRIES;
 use serde::{Deserialize, Serialize};
 
 #[derive(Debug, PartialEq, Serialize, Deserialize)]
@@ -5,6 +12,52 @@ pub struct GlobalConfig {
     pub services: Vec<ServiceConfig>,
     pub interfaces: Vec<InterfaceConfig>,
     pub ip_mac_list: Vec<IpMac>,
+    #[serde(default)]
+    pub local_port_range: PortRange,
+}
+
+impl GlobalConfig {
+    pub fn validate(&self) -> Result<(), anyhow::Error> {
+        self.local_port_range.validate()?;
+
+        let mut local_ips: Vec<Ipv4Addr> = vec![];
+        for ip in self.interfaces.iter().flat_map(|i| i.local_ips.iter()) {
+            local_ips.push(
+                ip.parse::<Ipv4Addr>()
+                    .with_context(|| format!("invalid local ip {}", ip))?,
+            );
+        }
+
+        // a local endpoint inside the port range could be handed out as the source
+        // port of another connection on the same local ip
+        for service in self.services.iter() {
+            let local_endpoint: SocketAddr = service.local_endpoint.parse().with_context(|| {
+                format!(
+                    "invalid local endpoint {} of service {}",
+                    service.local_endpoint, service.name
+                )
+            })?;
+            let local_endpoint = match local_endpoint {
+                SocketAddr::V4(addr) => addr,
+                SocketAddr::V6(_) => bail!(
+                    "local endpoint {} of service {} is not an ipv4 endpoint",
+                    service.local_endpoint,
+                    service.name,
+                ),
+            };
+            let is_local_ip = local_ips.contains(local_endpoint.ip());
+            if is_local_ip && self.local_port_range.contains(local_endpoint.port()) {
+                bail!(
+                    "local endpoint {} of service {} collides with local port range {}..{}",
+                    service.local_endpoint,
+                    service.name,
+                    self.local_port_range.start,
+                    self.local_port_range.end,
+                );
+            }
+        }
+        Ok(())
+    }
 }
 
 #[derive(Debug, PartialEq, Serialize, Deserialize)]
@@ -26,3 +79,130 @@ pub struct IpMac {
     pub ip: String,
     pub mac: String,
 }
+
+/// Ports used as the source port of the connections to the servers, `end` is exclusive,
+/// so port 65535 can't be used.
+#[derive(Debug, PartialEq, Serialize, Deserialize)]
+pub struct PortRange {
+    pub start: u16,
+    pub end: u16,
+}
+
+impl PortRange {
+    pub fn ports(&self) -> Range<u16> {
+        self.start..self.end
+    }
+
+    pub fn contains(&self, port: u16) -> bool {
+        self.ports().contains(&port)
+    }
+
+    fn validate(&self) -> Result<(), anyhow::Error> {
+        if self.start == 0 {
+            bail!(
+                "local port range {}..{} contains port 0",
+                self.start,
+                self.end
+            );
+        }
+        if self.ports().is_empty() {
+            bail!("local port range {}..{} is empty", self.start, self.end);
+        }
+        if self.ports().len() > SERVICE_PORTS_MAX_ENTRIES as usize {
+            bail!(
+                "local port range {}..{} has more than {} ports",
+                self.start,
+                self.end,
+                SERVICE_PORTS_MAX_ENTRIES,
+            );
+        }
+        Ok(())
+    }
+}
+
+impl Default for PortRange {
+    fn default() -> Self {
+        PortRange {
+            start: 10000,
+            end: 60000,
+        }
+    }
+}
+
+mod test {
+    #[allow(dead_code)]
+    const CONFIG: &str = r#"
+services:
+  - name: web
+    local_endpoint: 192.168.1.10:80
+    servers:
+      - 10.0.0.2:80
+    is_tcp: true
+interfaces:
+  - name: eth0
+    local_ips:
+      - 192.168.1.10
+ip_mac_list: []
+"#;
+
+    #[test]
+    fn test_default_port_range() {
+        use super::{GlobalConfig, PortRange};
+
+        let cfg: GlobalConfig = serde_yaml::from_str(CONFIG).unwrap();
+
+        assert_eq!(cfg.local_port_range, PortRange::default());
+        assert!(cfg.validate().is_ok());
+    }
+
+    #[test]
+    fn test_invalid_port_range() {
+        use super::{GlobalConfig, PortRange};
+
+        let mut cfg: GlobalConfig = serde_yaml::from_str(CONFIG).unwrap();
+
+        cfg.local_port_range = PortRange {
+            start: 20000,
+            end: 20000,
+        };
+        assert!(cfg.validate().is_err());
+
+        cfg.local_port_range = PortRange {
+            start: 1000,
+            end: 60000,
+        };
+        assert!(cfg.validate().is_err());
+
+        cfg.local_port_range = PortRange { start: 0, end: 100 };
+        assert!(cfg.validate().is_err());
+    }
+
+    #[test]
+    fn test_port_range_collision() {
+        use super::{GlobalConfig, PortRange};
+
+        let mut cfg: GlobalConfig = serde_yaml::from_str(CONFIG).unwrap();
+
+        cfg.local_port_range = PortRange { start: 1, end: 100 };
+        assert!(cfg.validate().is_err());
+
+        // only the ports on the local ips are handed out
+        cfg.services[0].local_endpoint = "192.168.1.11:80".to_string();
+        assert!(cfg.validate().is_ok());
+    }
+
+    #[test]
+    fn test_invalid_addresses() {
+        use super::GlobalConfig;
+
+        let mut cfg: GlobalConfig = serde_yaml::from_str(CONFIG).unwrap();
+
+        cfg.services[0].local_endpoint = "[::1]:80".to_string();
+        assert!(cfg.validate().is_err());
+
+        let mut cfg: GlobalConfig = serde_yaml::from_str(CONFIG).unwrap();
+
+        cfg.interfaces[0].local_ips.push("192.168.1".to_string());
+        assert!(cfg.validate().is_err());
+    }
+}
@@ -1,11 +1,11 @@
 use anyhow::{Context, Ok};
-use aya::maps::{HashMap as AyaHashmap, MapData as AyaMapData, Queue, RingBuf};
+use aya::maps::{HashMap as AyaHashmap, MapData as AyaMapData, PerCpuArray, Queue, RingBuf};
 use aya::programs::{Xdp, XdpFlags};
 use aya::{include_bytes_aligned, Bpf};
 use aya_log::BpfLogger;
 use clap::Parser;
 use config::GlobalConfig;
-use folonet_common::Notification;
+use folonet_common::{maps::DropReason, Notification};
 use log::{debug, error, info, warn};
 use std::collections::HashMap;
 use std::fs;
@@ -13,9 +13,10 @@ use std::net::Ipv4Addr;
 use std::ops::Deref;
 use std::os::fd::AsRawFd;
 use std::sync::Arc;
+use std::time::Duration;
 use tokio::io::unix::AsyncFd;
 use tokio::runtime::Runtime;
-use tokio::signal;
+use tokio::signal::{self, unix::SignalKind};
 
 use crate::endpoint::{
     endpoint_pair_from_notification, mac_from_string, set_server_ip, Endpoint, UConnection,
@@ -58,6 +59,36 @@ fn get_bpf() -> Bpf {
     bpf
 }
 
+// the XDP program only counts the packets it drops, report the increase periodically
+async fn report_drops(drop_counter: PerCpuArray<AyaMapData, u64>) {
+    let mut reported = [0u64; DropReason::COUNT as usize];
+    let mut interval = tokio::time::interval(Duration::from_secs(10));
+    loop {
+        interval.tick().await;
+        for reason in DropReason::ALL {
+            let values = drop_counter.get(&reason.index(), 0);
+            if let Err(e) = &values {
+                error!(
+                    "failed to read drop counter of {:?}, error detail: {:?}",
+                    reason, e,
+                );
+                continue;
+            }
+            let total: u64 = values.unwrap().iter().sum();
+            let last = &mut reported[reason.index() as usize];
+            if total > *last {
+                warn!(
+                    "dropped {} packets of new connections for {:?}, {} in total",
+                    total - *last,
+                    reason,
+                    total,
+                );
+                *last = total;
+            }
+        }
+    }
+}
+
 #[tokio::main]
 async fn main() -> Result<(), anyhow::Error> {
     env_logger::init();
@@ -82,6 +113,7 @@ async fn main() -> Result<(), anyhow::Error> {
 
     let cfg_str = fs::read_to_string("./config.yaml").unwrap();
     let global_cfg: GlobalConfig = serde_yaml::from_str(cfg_str.as_str()).unwrap();
+    global_cfg.validate().context("invalid config")?;
 
     // parse intreface config
     let mut local_ip_map: AyaHashmap<_, u32, u32> =
@@ -136,6 +168,10 @@ async fn main() -> Result<(), anyhow::Error> {
         // .context("failed to attach the XDP program with default flags - try changing XdpFlags::default() to XdpFlags::SKB_MODE").unwrap();
     });
 
+    let drop_counter: PerCpuArray<_, u64> =
+        PerCpuArray::try_from(bpf.take_map("DROP_COUNTER").unwrap()).unwrap();
+    tokio::spawn(report_drops(drop_counter));
+
     let mut bpf_packet_event_map = bpf.take_map("PACKET_EVENT").unwrap();
     let bpf_connection_map = bpf.take_map("CONNECTION").unwrap();
 
@@ -170,8 +206,8 @@ async fn main() -> Result<(), anyhow::Error> {
             //         connection_map.clone(),
             //     )),
             // );
-            for i in 10000..60000 {
-                bpf_service_ports_map.push(i as u16, 0).unwrap();
+            for port in global_cfg.local_port_range.ports() {
+                bpf_service_ports_map.push(port, 0).unwrap();
             }
 
             let bpf_service_ports_map = Arc::new(tokio::sync::Mutex::new(bpf_service_ports_map));
@@ -245,8 +281,14 @@ async fn main() -> Result<(), anyhow::Error> {
         });
     });
 
-    info!("Waiting for Ctrl-C...");
-    signal::ctrl_c().await?;
+    // exit on Ctrl-C as well as SIGTERM, so the XDP program is detached when the
+    // agent is stopped by a service manager rather than a terminal
+    let mut sigterm = signal::unix::signal(SignalKind::terminate())?;
+    info!("Waiting for Ctrl-C or SIGTERM...");
+    tokio::select! {
+        ret = signal::ctrl_c() => ret?,
+        _ = sigterm.recv() => {}
+    }
     info!("Exiting...");
 
     Ok(())
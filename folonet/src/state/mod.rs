@@ -7,7 +7,7 @@ use std::{
 use aya::maps::{HashMap as AyaHashMap, MapData as AyaMapData, Queue};
 use enum_dispatch::enum_dispatch;
 use folonet_common::event::Packet;
-use log::info;
+use log::{error, info};
 use tokio::sync::mpsc;
 
 use crate::{
@@ -123,14 +123,25 @@ impl MsgHandler for ConnectionStateMgr {
         let port = self.port_map.remove(&conn);
         if let Some(port) = port {
             let mut ports_map = self.bpf_service_ports_map.lock().await;
-            ports_map.push(port, 0).unwrap();
+            if let Err(e) = ports_map.push(port, 0) {
+                error!(
+                    "failed to return port {} of connection {:?}, error detail: {:?}",
+                    port, conn, e,
+                );
+            }
         }
 
         let u_connections = self.connection_msp.remove(&conn);
         if let Some(u_conns) = u_connections {
             let mut conn_map = self.bpf_conn_map.lock().await;
-            conn_map.remove(&u_conns.0).unwrap();
-            conn_map.remove(&u_conns.1).unwrap();
+            for u_conn in [u_conns.0, u_conns.1] {
+                if let Err(e) = conn_map.remove(&u_conn) {
+                    error!(
+                        "failed to remove {:?} of connection {:?}, error detail: {:?}",
+                        u_conn, conn, e,
+                    );
+                }
+            }
         }
 
         info!("remove connection {:?}", conn);
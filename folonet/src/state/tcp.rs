@@ -1,8 +1,7 @@
 use std::time::Duration;
 
-use anyhow::Ok;
 use folonet_common::event::Packet;
-use log::{debug, info};
+use log::{debug, error, info, warn};
 use rust_fsm::*;
 use tokio::sync::mpsc;
 
@@ -88,12 +87,18 @@ impl MsgHandler for ConnectionState {
     type MsgType = PacketMsg;
 
     async fn handle_message(&mut self, msg: PacketMsg) {
-        let _ = self.client.handle_packet_event(&msg).await;
-        let _ = self.server.handle_packet_event(&msg).await;
+        self.client.handle_packet_event(&msg).await;
+        self.server.handle_packet_event(&msg).await;
 
         if self.client.is_closed() && self.server.is_closed() {
             if let Some(sender) = &self.close_event_sender {
-                let _ = sender.send(CloseMsg::new(msg.from, msg.to)).await;
+                if let Err(e) = sender.send(CloseMsg::new(msg.from, msg.to)).await {
+                    error!(
+                        "failed to send close message for connection {:?}, error detail: {:?}",
+                        msg.connection(),
+                        e,
+                    );
+                }
             }
         }
     }
@@ -104,7 +109,13 @@ pub type TcpConnState = MsgWorker<ConnectionState>;
 impl PacketHandler for TcpConnState {
     async fn handle_packet(&mut self, packet: PacketMsg) {
         if let Some(sender) = self.msg_sender() {
-            let _ = sender.send(packet).await;
+            let conn = packet.connection();
+            if let Err(e) = sender.send(packet).await {
+                error!(
+                    "failed to send packet of connection {:?}, error detail: {:?}",
+                    conn, e,
+                );
+            }
         }
     }
 }
@@ -126,7 +137,14 @@ impl TcpFsmState {
     pub fn new(e: &Endpoint) -> Self {
         let mut fsm = StateMachine::<TCP>::new();
         if e.is_server_side() {
-            let _ = fsm.consume(&TCPInput::PassiveOpen);
+            if let Err(err) = fsm.consume(&TCPInput::PassiveOpen) {
+                error!(
+                    "{} failed to consume input {:?}, error detail: {:?}",
+                    e.to_string(),
+                    TCPInput::PassiveOpen,
+                    err,
+                );
+            }
         }
         TcpFsmState {
             e: *e,
@@ -140,10 +158,10 @@ impl TcpFsmState {
         self.fsm.state() == &TCPState::Closed
     }
 
-    pub async fn handle_packet_event(&mut self, msg: &PacketMsg) -> Result<(), anyhow::Error> {
+    pub async fn handle_packet_event(&mut self, msg: &PacketMsg) {
         let packet = match msg.packet {
             Some(p) => p,
-            _ => return Ok(()),
+            _ => return,
         };
 
         let direction = msg.direction(&self.e);
@@ -158,7 +176,17 @@ impl TcpFsmState {
         self.check_input(&packet, &direction).iter().for_each(|e| {
             let old_state = self.fsm.state().clone();
 
-            let _ = self.fsm.consume(e);
+            // a packet may yield inputs the current state doesn't accept, e.g. the
+            // ReceiveSyn of a SYN-ACK after ReceiveSynAck
+            if let Err(err) = self.fsm.consume(e) {
+                warn!(
+                    "{} failed to consume input {:?} in state {:?}, error detail: {:?}",
+                    self.e.to_string(),
+                    e,
+                    old_state,
+                    err,
+                );
+            }
 
             info!(
                 "{} input: {:?}, from {:?} to {:?}",
@@ -192,14 +220,19 @@ impl TcpFsmState {
         if self.fsm.state() == &TCPState::TimeWait {
             debug!("{} into time wait.", self.e.to_string());
             // tokio::time::sleep(Duration::from_secs(5)).await;
-            let _ = self.fsm.consume(&TCPInput::TimeExpired);
+            if let Err(e) = self.fsm.consume(&TCPInput::TimeExpired) {
+                error!(
+                    "{} failed to consume input {:?}, error detail: {:?}",
+                    self.e.to_string(),
+                    TCPInput::TimeExpired,
+                    e,
+                );
+            }
         }
 
         if self.fsm.state() == &TCPState::Closed {
             debug!("{} closed.", self.e.to_string());
         }
-
-        Ok(())
     }
 
     #[inline(always)]